@@ -0,0 +1,104 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConditionType of an UpboundAgent.
+type ConditionType string
+
+// Condition types.
+const (
+	// TypeReady indicates the managed agent Deployment is available.
+	TypeReady ConditionType = "Ready"
+
+	// TypeVPAUnavailable indicates that VPA management was requested but the
+	// VerticalPodAutoscaler API is not installed on the cluster.
+	TypeVPAUnavailable ConditionType = "VPAUnavailable"
+)
+
+// UpboundAgentSpec specifies the desired state of an Upbound Agent
+// Deployment.
+type UpboundAgentSpec struct {
+	// TokenSecretRef references the Secret containing the control plane
+	// token that the agent authenticates with.
+	TokenSecretRef corev1.LocalObjectReference `json:"tokenSecretRef"`
+
+	// Image is the agent container image to run.
+	Image string `json:"image"`
+
+	// Replicas is the number of desired agent Pods.
+	// +kubebuilder:default=1
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Resources are the compute resources required by the agent container.
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Env are additional environment variables to set on the agent
+	// container, on top of the ones derived from TokenSecretRef.
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// Args are additional arguments to pass to the agent container.
+	Args []string `json:"args,omitempty"`
+
+	// NodeSelector constrains which nodes the agent Pod may be scheduled on.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations allow the agent Pod to be scheduled onto nodes with
+	// matching taints.
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+}
+
+// UpboundAgentStatus represents the observed state of an Upbound Agent.
+type UpboundAgentStatus struct {
+	// ObservedGeneration is the most recent generation observed by the
+	// controller.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions of the resource.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// LastTokenSyncTime is the last time the token Secret was read and
+	// applied to the agent Deployment.
+	LastTokenSyncTime *metav1.Time `json:"lastTokenSyncTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Namespaced,categories=crossplane
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+
+// An UpboundAgent installs and configures the Upbound Agent Deployment that
+// connects a Control Plane to Upbound.
+type UpboundAgent struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   UpboundAgentSpec   `json:"spec"`
+	Status UpboundAgentStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// UpboundAgentList contains a list of UpboundAgent.
+type UpboundAgentList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []UpboundAgent `json:"items"`
+}
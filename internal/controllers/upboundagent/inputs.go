@@ -0,0 +1,187 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upboundagent
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"sort"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+
+	agentv1alpha1 "github.com/upbound/universal-crossplane/apis/agent/v1alpha1"
+)
+
+const (
+	// configMapAgentInputs optionally carries per-install parameterization
+	// for the agent Deployment, keyed by the UpboundAgent's namespace.
+	configMapAgentInputs = "upbound-agent-inputs"
+
+	keyInputs    = "inputs"
+	keyOverrides = "overrides"
+)
+
+const (
+	errGetInputsConfigMap = "failed to get agent inputs config map"
+	errParseInputs        = "failed to parse inputs as a map of string to string"
+	errRenderInputs       = "failed to render inputs into agent deployment spec"
+	errParseOverrides     = "failed to parse overrides as a JSON patch"
+	errMarshalSpec        = "failed to marshal agent deployment spec"
+	errApplyOverrides     = "failed to apply overrides to agent deployment spec"
+	errUnmarshalSpec      = "failed to unmarshal patched agent deployment spec"
+)
+
+// inputTokenPattern matches ${input.<name>} tokens in strings that make up
+// the agent Deployment spec.
+var inputTokenPattern = regexp.MustCompile(`\$\{input\.([A-Za-z0-9_.-]+)\}`)
+
+// applyInputs renders the upbound-agent-inputs ConfigMap, if any exists in
+// a's namespace, on top of spec: first substituting ${input.foo} tokens
+// found in the container image/args/env values, then applying a JSON patch
+// of overrides. It also copies the ConfigMap's labels and annotations onto
+// meta, following the same CommonMetadata convention used elsewhere to
+// propagate user-supplied metadata onto managed objects.
+//
+// An absent ConfigMap is not an error; spec and meta are returned
+// unmodified. An unresolved input token or an invalid/non-applicable
+// override is returned as an error, since there is no sensible Deployment to
+// reconcile towards in that case. The error is not terminal: Reconcile
+// returns it and the UpboundAgent is retried with the controller's
+// rate-limited backoff.
+func (r *Reconciler) applyInputs(ctx context.Context, a *agentv1alpha1.UpboundAgent, spec appsv1.DeploymentSpec, meta *metav1.ObjectMeta) (appsv1.DeploymentSpec, error) {
+	cm := &corev1.ConfigMap{}
+	err := r.client.Get(ctx, types.NamespacedName{Name: configMapAgentInputs, Namespace: a.Namespace}, cm)
+	if kerrors.IsNotFound(err) {
+		return spec, nil
+	}
+	if err != nil {
+		return spec, errors.Wrap(err, errGetInputsConfigMap)
+	}
+
+	copyCommonMetadata(meta, cm)
+
+	if raw, ok := cm.Data[keyInputs]; ok {
+		inputs := map[string]string{}
+		if err := yaml.Unmarshal([]byte(raw), &inputs); err != nil {
+			return spec, errors.Wrap(err, errParseInputs)
+		}
+		if err := renderInputs(&spec, inputs); err != nil {
+			return spec, errors.Wrap(err, errRenderInputs)
+		}
+	}
+
+	if raw, ok := cm.Data[keyOverrides]; ok && raw != "" {
+		patched, err := applyOverrides(spec, []byte(raw))
+		if err != nil {
+			return spec, err
+		}
+		spec = patched
+	}
+
+	return spec, nil
+}
+
+// renderInputs substitutes ${input.foo} tokens found in the agent
+// container's image, args and env values with the corresponding entry from
+// inputs, returning an error naming any token that could not be resolved.
+func renderInputs(spec *appsv1.DeploymentSpec, inputs map[string]string) error {
+	var unresolved []string
+	render := func(s string) string {
+		return inputTokenPattern.ReplaceAllStringFunc(s, func(tok string) string {
+			name := inputTokenPattern.FindStringSubmatch(tok)[1]
+			v, ok := inputs[name]
+			if !ok {
+				unresolved = append(unresolved, name)
+				return tok
+			}
+			return v
+		})
+	}
+
+	for i := range spec.Template.Spec.Containers {
+		c := &spec.Template.Spec.Containers[i]
+		c.Image = render(c.Image)
+		for j := range c.Args {
+			c.Args[j] = render(c.Args[j])
+		}
+		for k := range c.Env {
+			c.Env[k].Value = render(c.Env[k].Value)
+		}
+	}
+
+	if len(unresolved) > 0 {
+		sort.Strings(unresolved)
+		return errors.Errorf("unresolved input(s): %v", unresolved)
+	}
+	return nil
+}
+
+// applyOverrides applies a JSON patch document to spec, returning the
+// resulting DeploymentSpec.
+func applyOverrides(spec appsv1.DeploymentSpec, raw []byte) (appsv1.DeploymentSpec, error) {
+	patch, err := jsonpatch.DecodePatch(raw)
+	if err != nil {
+		return spec, errors.Wrap(err, errParseOverrides)
+	}
+
+	specJSON, err := json.Marshal(spec)
+	if err != nil {
+		return spec, errors.Wrap(err, errMarshalSpec)
+	}
+
+	patchedJSON, err := patch.Apply(specJSON)
+	if err != nil {
+		return spec, errors.Wrap(err, errApplyOverrides)
+	}
+
+	patched := appsv1.DeploymentSpec{}
+	if err := json.Unmarshal(patchedJSON, &patched); err != nil {
+		return spec, errors.Wrap(err, errUnmarshalSpec)
+	}
+	return patched, nil
+}
+
+// copyCommonMetadata merges labels and annotations from the inputs
+// ConfigMap onto dst, without overwriting keys dst already sets.
+func copyCommonMetadata(dst *metav1.ObjectMeta, cm *corev1.ConfigMap) {
+	if len(cm.Labels) > 0 {
+		if dst.Labels == nil {
+			dst.Labels = map[string]string{}
+		}
+		for k, v := range cm.Labels {
+			if _, ok := dst.Labels[k]; !ok {
+				dst.Labels[k] = v
+			}
+		}
+	}
+	if len(cm.Annotations) > 0 {
+		if dst.Annotations == nil {
+			dst.Annotations = map[string]string{}
+		}
+		for k, v := range cm.Annotations {
+			if _, ok := dst.Annotations[k]; !ok {
+				dst.Annotations[k] = v
+			}
+		}
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upboundagent
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRenderInputs(t *testing.T) {
+	cases := map[string]struct {
+		spec    appsv1.DeploymentSpec
+		inputs  map[string]string
+		want    appsv1.DeploymentSpec
+		wantErr bool
+	}{
+		"SubstitutesImageArgsAndEnv": {
+			spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{
+								Image: "registry/agent:${input.tag}",
+								Args:  []string{"--region=${input.region}"},
+								Env:   []corev1.EnvVar{{Name: "REGION", Value: "${input.region}"}},
+							},
+						},
+					},
+				},
+			},
+			inputs: map[string]string{"tag": "v1.2.3", "region": "us-west-2"},
+			want: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{
+								Image: "registry/agent:v1.2.3",
+								Args:  []string{"--region=us-west-2"},
+								Env:   []corev1.EnvVar{{Name: "REGION", Value: "us-west-2"}},
+							},
+						},
+					},
+				},
+			},
+		},
+		"UnresolvedTokenIsError": {
+			spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{Image: "registry/agent:${input.tag}"},
+						},
+					},
+				},
+			},
+			inputs:  map[string]string{},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			spec := tc.spec.DeepCopy()
+			err := renderInputs(spec, tc.inputs)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("renderInputs(...): want error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("renderInputs(...): unexpected error: %v", err)
+			}
+			if diff := cmpDeploymentSpec(*spec, tc.want); diff != "" {
+				t.Fatalf("renderInputs(...): %s", diff)
+			}
+		})
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	base := appsv1.DeploymentSpec{
+		Template: corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "agent", Image: "registry/agent:v1"}},
+			},
+		},
+	}
+
+	t.Run("AppliesAValidPatch", func(t *testing.T) {
+		patch := []byte(`[{"op":"replace","path":"/template/spec/containers/0/image","value":"registry/agent:v2"}]`)
+		got, err := applyOverrides(base, patch)
+		if err != nil {
+			t.Fatalf("applyOverrides(...): unexpected error: %v", err)
+		}
+		if got.Template.Spec.Containers[0].Image != "registry/agent:v2" {
+			t.Fatalf("applyOverrides(...): got image %q, want %q", got.Template.Spec.Containers[0].Image, "registry/agent:v2")
+		}
+	})
+
+	t.Run("MalformedPatchIsError", func(t *testing.T) {
+		if _, err := applyOverrides(base, []byte(`not json`)); err == nil {
+			t.Fatal("applyOverrides(...): want error for malformed patch, got nil")
+		}
+	})
+
+	t.Run("PatchTargetingMissingPathIsError", func(t *testing.T) {
+		patch := []byte(`[{"op":"replace","path":"/template/spec/containers/5/image","value":"registry/agent:v2"}]`)
+		if _, err := applyOverrides(base, patch); err == nil {
+			t.Fatal("applyOverrides(...): want error for out-of-range patch path, got nil")
+		}
+	})
+}
+
+func TestCopyCommonMetadata(t *testing.T) {
+	dst := &metav1.ObjectMeta{
+		Labels:      map[string]string{"existing": "keep-me"},
+		Annotations: nil,
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      map[string]string{"existing": "overwritten", "added": "added-value"},
+			Annotations: map[string]string{"note": "from-configmap"},
+		},
+	}
+
+	copyCommonMetadata(dst, cm)
+
+	if dst.Labels["existing"] != "keep-me" {
+		t.Fatalf("copyCommonMetadata(...): existing label was overwritten, got %q", dst.Labels["existing"])
+	}
+	if dst.Labels["added"] != "added-value" {
+		t.Fatalf("copyCommonMetadata(...): new label was not merged, got %q", dst.Labels["added"])
+	}
+	if dst.Annotations["note"] != "from-configmap" {
+		t.Fatalf("copyCommonMetadata(...): annotation was not merged, got %q", dst.Annotations["note"])
+	}
+}
+
+// cmpDeploymentSpec returns a human-readable description of the first
+// difference in the containers' images, args and env values, or "" if those
+// are equal; other fields are not compared. Kept minimal and local to this file rather than pulling
+// in a diff library this repo doesn't otherwise depend on.
+func cmpDeploymentSpec(a, b appsv1.DeploymentSpec) string {
+	ac, bc := a.Template.Spec.Containers, b.Template.Spec.Containers
+	if len(ac) != len(bc) {
+		return "container count differs"
+	}
+	for i := range ac {
+		if ac[i].Image != bc[i].Image {
+			return "image differs: got " + ac[i].Image + ", want " + bc[i].Image
+		}
+		if len(ac[i].Args) != len(bc[i].Args) {
+			return "args differ"
+		}
+		for j := range ac[i].Args {
+			if ac[i].Args[j] != bc[i].Args[j] {
+				return "arg differs: got " + ac[i].Args[j] + ", want " + bc[i].Args[j]
+			}
+		}
+		if len(ac[i].Env) != len(bc[i].Env) {
+			return "env differs"
+		}
+		for j := range ac[i].Env {
+			if ac[i].Env[j].Value != bc[i].Env[j].Value {
+				return "env value differs: got " + ac[i].Env[j].Value + ", want " + bc[i].Env[j].Value
+			}
+		}
+	}
+	return ""
+}
@@ -12,52 +12,262 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// Package upboundagent reconciles UpboundAgent resources, each of which
+// owns and manages a single Upbound Agent Deployment.
 package upboundagent
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
-	"github.com/crossplane/crossplane-runtime/pkg/meta"
-	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	xpmeta "github.com/crossplane/crossplane-runtime/pkg/meta"
 	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	vpav1 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	agentv1alpha1 "github.com/upbound/universal-crossplane/apis/agent/v1alpha1"
 	internalmeta "github.com/upbound/universal-crossplane/internal/meta"
 )
 
 const (
 	reconcileTimeout = 1 * time.Minute
 
-	configMapUXPVersions   = "universal-crossplane-config"
-	deploymentUpboundAgent = "upbound-agent"
-	keyToken               = "token"
+	keyToken           = "token"
+	agentContainerName = "agent"
+	envVarToken        = "UPBOUND_AGENT_TOKEN"
+
+	// labelKeyAgentName disambiguates the Deployments owned by different
+	// UpboundAgents in the same namespace. It must be included in both the
+	// Deployment's selector and its pod template labels: a selector built
+	// from labelKeyManagedBy alone would match every agent Deployment's
+	// Pods in the namespace, not just its own.
+	labelKeyAgentName = "agent.upbound.io/agent-name"
 )
 
 const (
-	errGetVersionsConfigMap = "failed to get versions config map"
-	errGetSecret            = "failed to get control plane token secret"
-	errDeleteDeployment     = "failed to delete agent deployment"
-	errSyncDeployment       = "failed to sync agent deployment"
-)
+	errGetUpboundAgent = "failed to get upbound agent"
+	errGetSecret       = "failed to get control plane token secret"
+	errSyncDeployment  = "failed to sync agent deployment"
+	errUpdateStatus    = "failed to update upbound agent status"
+	errSyncVPA         = "failed to sync agent vertical pod autoscaler"
+	errGCVPA           = "failed to garbage collect agent vertical pod autoscaler"
+	errUnknownGVK      = "failed to determine managed object kind"
 
-var (
-	secretsKind     = schema.GroupVersionKind{Group: "", Version: "v1", Kind: "Secret"}
-	deploymentsKind = schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	errIndexTokenSecretRef = "failed to index upbound agents by token secret ref"
 )
 
+// Config configures the controller created by Setup. It is a struct rather
+// than a growing list of positional arguments so that new tunables don't
+// keep breaking the Setup signature.
+// Note: the controller-runtime cache resync period is a property of the
+// Manager, set via manager.Options.SyncPeriod at the call site that
+// constructs mgr, and isn't
+// something this package's Setup can honor after the fact, so it doesn't
+// belong on this Config.
+type Config struct {
+	// PollInterval is a requeue-after applied on every successful reconcile
+	// so that drift is detected periodically even when no Secret or
+	// Deployment event would otherwise trigger one.
+	PollInterval time.Duration
+
+	// MaxReconcileRate is the maximum number of UpboundAgents this
+	// controller will reconcile concurrently, and bounds the rate at which
+	// a single UpboundAgent is retried after an error.
+	MaxReconcileRate int
+
+	// EnableVPA turns on management of a VerticalPodAutoscaler targeting the
+	// agent Deployment. It is a no-op, degrading gracefully, on clusters
+	// that do not have the VPA API installed.
+	EnableVPA bool
+
+	// VPAUpdateMode is the UpdateMode set on the managed VPA, e.g. "Off",
+	// "Initial" or "Auto". Only used when EnableVPA is true.
+	VPAUpdateMode vpav1.UpdateMode
+
+	// VPAMinAllowed and VPAMaxAllowed bound the resources the VPA is
+	// allowed to recommend/apply for the agent container. Only used when
+	// EnableVPA is true.
+	VPAMinAllowed corev1.ResourceList
+	VPAMaxAllowed corev1.ResourceList
+}
+
+// DefaultConfig returns the Config used when none is supplied.
+func DefaultConfig() Config {
+	return Config{
+		MaxReconcileRate: 1,
+		VPAUpdateMode:    vpav1.UpdateModeAuto,
+	}
+}
+
+// indexTokenSecretRef is the name of the field index used to look up which
+// UpboundAgents reference a given token Secret, so that Secret events can be
+// mapped back to the UpboundAgents that need reconciling.
+const indexTokenSecretRef = "spec.tokenSecretRef.name"
+
+// Setup adds a controller that reconciles UpboundAgent resources, each of
+// which manages an Upbound Agent Deployment.
+func Setup(mgr ctrl.Manager, l logging.Logger, cfg Config) error {
+	name := "upboundAgent"
+
+	if cfg.MaxReconcileRate < 1 {
+		cfg.MaxReconcileRate = 1
+	}
+	if cfg.EnableVPA && cfg.VPAUpdateMode == "" {
+		cfg.VPAUpdateMode = vpav1.UpdateModeAuto
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &agentv1alpha1.UpboundAgent{}, indexTokenSecretRef, func(obj client.Object) []string {
+		a := obj.(*agentv1alpha1.UpboundAgent)
+		if a.Spec.TokenSecretRef.Name == "" {
+			return nil
+		}
+		return []string{a.Spec.TokenSecretRef.Name}
+	}); err != nil {
+		return errors.Wrap(err, errIndexTokenSecretRef)
+	}
+
+	vpaAvailable := vpaCRDRegistered(mgr.GetRESTMapper())
+	if cfg.EnableVPA && !vpaAvailable {
+		l.Info("VerticalPodAutoscaler API not found on cluster, disabling VPA management")
+	}
+
+	r := NewReconciler(mgr,
+		WithLogger(l.WithValues("controller", name)),
+		WithPollInterval(cfg.PollInterval),
+		WithVPA(cfg.EnableVPA, vpaAvailable, cfg.VPAUpdateMode, cfg.VPAMinAllowed, cfg.VPAMaxAllowed),
+	)
+
+	// The reconciler only ever needs the token bytes out of the Secret and
+	// nothing from the Deployment spec, so both watches are registered as
+	// metadata-only projections. This keeps the informer caches from
+	// holding full object bodies for every Secret and Deployment in a
+	// namespace, which matters on clusters where the agent is colocated
+	// with a lot of unrelated workloads.
+	c, err := ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		// Status().Update in updateStatus does not bump Generation, so a
+		// generation-changed predicate keeps that write from being picked
+		// back up by this same watch and causing a perpetual reconcile
+		// loop. Secret/Deployment-driven reconciles are unaffected since
+		// they come through Watches/Owns below.
+		For(&agentv1alpha1.UpboundAgent{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&appsv1.Deployment{}, builder.OnlyMetadata).
+		Watches(
+			&source.Kind{Type: &corev1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(r.tokenSecretToRequests),
+			builder.OnlyMetadata,
+		).
+		Watches(
+			&source.Kind{Type: &corev1.ConfigMap{}},
+			handler.EnqueueRequestsFromMapFunc(r.inputsConfigMapToRequests),
+			builder.OnlyMetadata,
+		).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: cfg.MaxReconcileRate,
+			RateLimiter:             newRateLimiter(cfg.MaxReconcileRate),
+		}).
+		Build(r)
+	if err != nil {
+		return err
+	}
+
+	// The Deployment watch above already covers its kind, so mark it seen
+	// up front; everything else syncAgentDeployment and friends pass to
+	// Reconciler.Manage is watched lazily the first time it is produced,
+	// see typeTracker.
+	r.ctrl = c
+	r.tracked.markSeen(appsv1.SchemeGroupVersion.WithKind("Deployment"))
+	return nil
+}
+
+// tokenSecretToRequests maps a token Secret to reconcile requests for every
+// UpboundAgent that references it by name, using the field index registered
+// in Setup.
+func (r *Reconciler) tokenSecretToRequests(ctx context.Context, obj client.Object) []reconcile.Request {
+	agents := &agentv1alpha1.UpboundAgentList{}
+	if err := r.client.List(ctx, agents,
+		client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{indexTokenSecretRef: obj.GetName()},
+	); err != nil {
+		r.log.Info("Failed to list upbound agents for token secret", "secret", obj.GetName(), "error", err)
+		return nil
+	}
+
+	reqs := make([]reconcile.Request, 0, len(agents.Items))
+	for _, a := range agents.Items {
+		reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: a.Name, Namespace: a.Namespace}})
+	}
+	return reqs
+}
+
+// inputsConfigMapToRequests maps the upbound-agent-inputs ConfigMap to
+// reconcile requests for every UpboundAgent in its namespace, since any of
+// them may have its Deployment spec rendered from it via applyInputs. This
+// lets a fix to a previously invalid ConfigMap (which would otherwise have
+// left the UpboundAgent erroring until its next rate-limited retry) be
+// picked up as soon
+// as it lands.
+func (r *Reconciler) inputsConfigMapToRequests(ctx context.Context, obj client.Object) []reconcile.Request {
+	if obj.GetName() != configMapAgentInputs {
+		return nil
+	}
+
+	agents := &agentv1alpha1.UpboundAgentList{}
+	if err := r.client.List(ctx, agents, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.log.Info("Failed to list upbound agents for inputs config map", "configMap", obj.GetName(), "error", err)
+		return nil
+	}
+
+	reqs := make([]reconcile.Request, 0, len(agents.Items))
+	for _, a := range agents.Items {
+		reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: a.Name, Namespace: a.Namespace}})
+	}
+	return reqs
+}
+
+// vpaGK is the GroupKind of the VerticalPodAutoscaler CRD.
+var vpaGK = schema.GroupKind{Group: "autoscaling.k8s.io", Kind: "VerticalPodAutoscaler"}
+
+// vpaCRDRegistered reports whether the VerticalPodAutoscaler API is
+// installed on the cluster the Manager is connected to.
+func vpaCRDRegistered(rm meta.RESTMapper) bool {
+	_, err := rm.RESTMapping(vpaGK, vpav1.SchemeGroupVersion.Version)
+	return err == nil
+}
+
+// newRateLimiter returns a workqueue rate limiter bounded by maxRate,
+// combining per-item exponential backoff with an overall rate-limited
+// bucket so a single misbehaving UpboundAgent cannot starve the others.
+func newRateLimiter(maxRate int) workqueue.RateLimiter {
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(5*time.Millisecond, 1000*time.Second),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(maxRate), maxRate)},
+	)
+}
+
 // ReconcilerOption is used to configure the Reconciler.
 type ReconcilerOption func(*Reconciler)
 
@@ -68,40 +278,56 @@ func WithLogger(log logging.Logger) ReconcilerOption {
 	}
 }
 
-// Setup adds a controller that reconciles on control plane token secret and manages Upbound Agent deployment
-func Setup(mgr ctrl.Manager, l logging.Logger, ds appsv1.DeploymentSpec, ts string) error {
-	name := "upboundAgent"
-
-	r := NewReconciler(mgr, ds, ts,
-		WithLogger(l.WithValues("controller", name)),
-	)
+// WithPollInterval specifies how often the Reconciler should requeue a
+// successfully reconciled UpboundAgent to detect drift.
+func WithPollInterval(d time.Duration) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.pollInterval = d
+	}
+}
 
-	return ctrl.NewControllerManagedBy(mgr).
-		Named(name).
-		For(&corev1.Secret{}).
-		Owns(&appsv1.Deployment{}).
-		WithEventFilter(resource.NewPredicates(resource.AnyOf(
-			resource.AllOf(IsOfKind(secretsKind, mgr.GetScheme()), resource.IsNamed(ts)),
-			resource.AllOf(IsOfKind(deploymentsKind, mgr.GetScheme()), resource.IsNamed(deploymentUpboundAgent)),
-		))).
-		Complete(r)
+// WithVPA configures whether the Reconciler should manage a
+// VerticalPodAutoscaler for the agent Deployment, and with what bounds.
+// available indicates whether the VPA API was detected on the cluster at
+// startup; VPA management is skipped whenever it is false, regardless of
+// enabled.
+func WithVPA(enabled, available bool, updateMode vpav1.UpdateMode, minAllowed, maxAllowed corev1.ResourceList) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.vpaEnabled = enabled
+		r.vpaAvailable = available
+		r.vpaUpdateMode = updateMode
+		r.vpaMinAllowed = minAllowed
+		r.vpaMaxAllowed = maxAllowed
+	}
 }
 
-// Reconciler reconciles on control plane token secret and manages Upbound Agent deployment
+// Reconciler reconciles an UpboundAgent and the Deployment it owns.
 type Reconciler struct {
-	client         client.Client
-	deploymentSpec appsv1.DeploymentSpec
-	tokenSecret    string
-	log            logging.Logger
+	client       client.Client
+	scheme       *runtime.Scheme
+	log          logging.Logger
+	pollInterval time.Duration
+
+	vpaEnabled    bool
+	vpaAvailable  bool
+	vpaUpdateMode vpav1.UpdateMode
+	vpaMinAllowed corev1.ResourceList
+	vpaMaxAllowed corev1.ResourceList
+
+	// ctrl and tracked back Manage: as sync methods start producing kinds
+	// Setup never hard-coded an Owns(...) for, ctrl.Watch is called for
+	// that kind the first time it is seen instead of requiring every new
+	// managed object kind to be wired into Setup up front.
+	ctrl    controller.Controller
+	tracked typeTracker
 }
 
-// NewReconciler returns a new reconciler
-func NewReconciler(mgr manager.Manager, ds appsv1.DeploymentSpec, ts string, opts ...ReconcilerOption) *Reconciler {
+// NewReconciler returns a new Reconciler.
+func NewReconciler(mgr manager.Manager, opts ...ReconcilerOption) *Reconciler {
 	r := &Reconciler{
-		client:         mgr.GetClient(),
-		deploymentSpec: ds,
-		tokenSecret:    ts,
-		log:            logging.NewNopLogger(),
+		client: mgr.GetClient(),
+		scheme: mgr.GetScheme(),
+		log:    logging.NewNopLogger(),
 	}
 
 	for _, f := range opts {
@@ -111,7 +337,56 @@ func NewReconciler(mgr manager.Manager, ds appsv1.DeploymentSpec, ts string, opt
 	return r
 }
 
-// Reconcile reconciles on control plane token secret and manages Upbound Agent deployment
+// typeTracker is a concurrency-safe set of GroupVersionKinds that have been
+// produced by a Manage call, used to watch each kind at most once.
+type typeTracker struct {
+	mu   sync.Mutex
+	seen map[schema.GroupVersionKind]bool
+}
+
+// markSeen records gvk as seen and reports whether it was new.
+func (t *typeTracker) markSeen(gvk schema.GroupVersionKind) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.seen == nil {
+		t.seen = map[schema.GroupVersionKind]bool{}
+	}
+	if t.seen[gvk] {
+		return false
+	}
+	t.seen[gvk] = true
+	return true
+}
+
+// Manage creates or updates obj via CreateOrUpdate, applying mutate, and
+// ensures the controller is watching obj's kind so that future external
+// changes to it are reconciled. The owning reference used for the watch's
+// event handler continues to be the UpboundAgent, so garbage collection is
+// unaffected by when a kind's watch was registered.
+func (r *Reconciler) Manage(ctx context.Context, obj client.Object, mutate func() error) error {
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.client, obj, mutate); err != nil {
+		return err
+	}
+
+	gvks, _, err := r.scheme.ObjectKinds(obj)
+	if err != nil || len(gvks) == 0 {
+		return errors.Wrap(err, errUnknownGVK)
+	}
+	gvk := gvks[0]
+
+	if !r.tracked.markSeen(gvk) {
+		return nil
+	}
+
+	return r.ctrl.Watch(
+		&source.Kind{Type: obj},
+		&handler.EnqueueRequestForOwner{OwnerType: &agentv1alpha1.UpboundAgent{}, IsController: true},
+	)
+}
+
+// Reconcile fetches the UpboundAgent, ensures its Deployment matches the
+// desired state and reports the result in the UpboundAgent's status.
 func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
 	log := r.log.WithValues("request", req)
 
@@ -119,89 +394,281 @@ func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reco
 	ctx, cancel := context.WithTimeout(ctx, reconcileTimeout)
 	defer cancel()
 
-	cm := &corev1.ConfigMap{}
-	err := r.client.Get(ctx, types.NamespacedName{Name: configMapUXPVersions, Namespace: req.Namespace}, cm)
-
-	// We create agent Deployment with an owner reference to the versions
-	// ConfigMap. The agent Deployment will be garbage collected if the
-	// ConfigMap no longer exists.
-	if kerrors.IsNotFound(err) {
-		return reconcile.Result{}, nil
-	}
-	if err != nil {
-		return reconcile.Result{}, errors.Wrap(err, errGetVersionsConfigMap)
+	a := &agentv1alpha1.UpboundAgent{}
+	if err := r.client.Get(ctx, req.NamespacedName, a); err != nil {
+		if kerrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, errors.Wrap(err, errGetUpboundAgent)
 	}
 
 	ts := &corev1.Secret{}
-	err = r.client.Get(ctx, types.NamespacedName{Name: req.Name, Namespace: req.Namespace}, ts)
-
-	// If the token Secret is deleted, we also want to clean up the agent
-	// Deployment.
+	err := r.client.Get(ctx, types.NamespacedName{Name: a.Spec.TokenSecretRef.Name, Namespace: a.Namespace}, ts)
 	if kerrors.IsNotFound(err) {
-		err := r.client.Delete(ctx, &appsv1.Deployment{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      deploymentUpboundAgent,
-				Namespace: cm.Namespace,
-			},
-		})
-		// If we fail to delete agent Deployment we should immediately try
-		// again. Otherwise we have nothing left to do.
-		return reconcile.Result{}, errors.Wrap(err, errDeleteDeployment)
+		log.Info("Token secret does not exist yet", "secret", a.Spec.TokenSecretRef.Name)
+		// We will get another event once the token Secret is created. No
+		// need to keep retrying until then.
+		return reconcile.Result{}, nil
 	}
 	if err != nil {
 		return reconcile.Result{}, errors.Wrap(err, errGetSecret)
 	}
 
-	// Ensure secret has token
-	t := ts.Data[keyToken]
-	if string(t) == "" {
-		log.Info("Secret does not contain a token for key", "secret", r.tokenSecret, "key", keyToken)
-		// We just log this as an error and do not return error since we will
-		// get another update when the secret is updated with token. No need to
-		// keep retrying until then.
+	if string(ts.Data[keyToken]) == "" {
+		log.Info("Secret does not contain a token for key", "secret", a.Spec.TokenSecretRef.Name, "key", keyToken)
+		// We just log this and do not return an error since we will get
+		// another update when the secret is updated with a token.
 		return reconcile.Result{}, nil
 	}
 
-	if err := r.syncAgentDeployment(ctx, cm); err != nil {
+	syncErr := r.syncAgentDeployment(ctx, a)
+	if syncErr != nil {
+		log.Info(syncErr.Error())
+	} else {
+		log.Info("Successfully synced Upbound Agent deployment!")
+		a.Status.LastTokenSyncTime = &metav1.Time{Time: time.Now()}
+	}
+
+	// Run regardless of syncErr: EnableVPA may have been turned off while
+	// the Deployment is persistently failing to sync for an unrelated
+	// reason, and gcVPA must still be reachable to clean up a stale VPA in
+	// that case.
+	if err := r.syncVPA(ctx, a); err != nil {
 		log.Info(err.Error())
-		return reconcile.Result{}, err
+		if syncErr == nil {
+			syncErr = err
+		}
+	}
+
+	if err := r.updateStatus(ctx, a, syncErr); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, errUpdateStatus)
 	}
 
-	log.Info("Successfully synced Upbound Agent deployment!")
-	return reconcile.Result{}, nil
+	if syncErr != nil {
+		return reconcile.Result{}, syncErr
+	}
+	return reconcile.Result{RequeueAfter: r.pollInterval}, nil
 }
 
-func (r *Reconciler) syncAgentDeployment(ctx context.Context, cm *corev1.ConfigMap) error {
+func (r *Reconciler) syncAgentDeployment(ctx context.Context, a *agentv1alpha1.UpboundAgent) error {
 	agentDeployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      deploymentUpboundAgent,
-			Namespace: cm.Namespace,
+			Name:      deploymentName(a),
+			Namespace: a.Namespace,
 			Labels: map[string]string{
 				internalmeta.LabelKeyManagedBy: internalmeta.LabelValueManagedBy,
 			},
-			OwnerReferences: []metav1.OwnerReference{meta.AsController(meta.TypedReferenceTo(cm, cm.GroupVersionKind()))},
+			OwnerReferences: []metav1.OwnerReference{xpmeta.AsController(xpmeta.TypedReferenceTo(a, a.GroupVersionKind()))},
 		},
 	}
 
+	spec, err := r.applyInputs(ctx, a, desiredDeploymentSpec(a), &agentDeployment.ObjectMeta)
+	if err != nil {
+		return errors.Wrap(err, errSyncDeployment)
+	}
+
 	// crossplane runtime NewAPIUpdatingApplicator causes constant updates on the object
 	// no matter it is really changed or not. This triggers another reconcile loop hence another
 	// update. NewAPIPatchingApplicator does not cause above but we need update rather than
 	// patch here (e.g. we removed an env var from agent deployment in an upcoming version).
-	_, err := controllerutil.CreateOrUpdate(ctx, r.client, agentDeployment, func() error {
-		agentDeployment.Spec = r.deploymentSpec
+	err = r.Manage(ctx, agentDeployment, func() error {
+		agentDeployment.Spec = spec
 		return nil
 	})
 	return errors.Wrap(err, errSyncDeployment)
 }
 
-// IsOfKind accepts objects that are of the supplied managed resource kind.
-// TODO(turkenh): move to crossplane-runtime?
-func IsOfKind(k schema.GroupVersionKind, ot runtime.ObjectTyper) resource.PredicateFn {
-	return func(obj runtime.Object) bool {
-		gvk, err := resource.GetKind(obj, ot)
-		if err != nil {
+// syncVPA creates/updates a VerticalPodAutoscaler targeting the agent
+// Deployment when VPA management is enabled and the VPA API is available,
+// and garbage collects any previously created VPA when it is disabled. It
+// does nothing when the VPA API is not available.
+func (r *Reconciler) syncVPA(ctx context.Context, a *agentv1alpha1.UpboundAgent) error {
+	if !r.vpaAvailable {
+		// Without the VPA API no VPA can exist, and a Delete would fail.
+		return nil
+	}
+	if !r.vpaEnabled {
+		return r.gcVPA(ctx, a)
+	}
+
+	vpa := &vpav1.VerticalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      deploymentName(a),
+			Namespace: a.Namespace,
+			Labels: map[string]string{
+				internalmeta.LabelKeyManagedBy: internalmeta.LabelValueManagedBy,
+			},
+			OwnerReferences: []metav1.OwnerReference{xpmeta.AsController(xpmeta.TypedReferenceTo(a, a.GroupVersionKind()))},
+		},
+	}
+
+	err := r.Manage(ctx, vpa, func() error {
+		vpa.Spec = vpav1.VerticalPodAutoscalerSpec{
+			TargetRef: &autoscalingv1.CrossVersionObjectReference{
+				APIVersion: appsv1.SchemeGroupVersion.String(),
+				Kind:       "Deployment",
+				Name:       deploymentName(a),
+			},
+			UpdatePolicy: &vpav1.PodUpdatePolicy{UpdateMode: &r.vpaUpdateMode},
+			ResourcePolicy: &vpav1.PodResourcePolicy{
+				ContainerPolicies: []vpav1.ContainerResourcePolicy{
+					{
+						ContainerName: agentContainerName,
+						MinAllowed:    r.vpaMinAllowed,
+						MaxAllowed:    r.vpaMaxAllowed,
+					},
+				},
+			},
+		}
+		return nil
+	})
+	return errors.Wrap(err, errSyncVPA)
+}
+
+// gcVPA deletes any VerticalPodAutoscaler previously created for a, ignoring
+// the case where it does not exist.
+func (r *Reconciler) gcVPA(ctx context.Context, a *agentv1alpha1.UpboundAgent) error {
+	err := r.client.Delete(ctx, &vpav1.VerticalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      deploymentName(a),
+			Namespace: a.Namespace,
+		},
+	})
+	if kerrors.IsNotFound(err) {
+		return nil
+	}
+	return errors.Wrap(err, errGCVPA)
+}
+
+// updateStatus sets observedGeneration and the Ready condition on the
+// UpboundAgent and persists them, retrying on write conflicts. The write is
+// skipped when it would not change anything observable. A successful sync
+// always carries a new LastTokenSyncTime, so in practice the write is only
+// skipped when a failed sync leaves the status as it already was.
+func (r *Reconciler) updateStatus(ctx context.Context, a *agentv1alpha1.UpboundAgent, syncErr error) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &agentv1alpha1.UpboundAgent{}
+		if err := r.client.Get(ctx, types.NamespacedName{Name: a.Name, Namespace: a.Namespace}, latest); err != nil {
+			return err
+		}
+
+		want := latest.Status.DeepCopy()
+		want.ObservedGeneration = latest.Generation
+		want.LastTokenSyncTime = a.Status.LastTokenSyncTime
+		withConditions := &agentv1alpha1.UpboundAgent{ObjectMeta: latest.ObjectMeta, Status: *want}
+		setReadyCondition(withConditions, syncErr)
+		r.setVPAUnavailableCondition(withConditions)
+
+		if statusUnchanged(latest.Status, withConditions.Status) {
+			return nil
+		}
+
+		latest.Status = withConditions.Status
+		return r.client.Status().Update(ctx, latest)
+	})
+}
+
+// statusUnchanged reports whether b is observably identical to a, ignoring
+// LastTransitionTime on conditions (which meta.SetStatusCondition only moves
+// when a condition's Status changes).
+func statusUnchanged(a, b agentv1alpha1.UpboundAgentStatus) bool {
+	if a.ObservedGeneration != b.ObservedGeneration || !a.LastTokenSyncTime.Equal(b.LastTokenSyncTime) {
+		return false
+	}
+	if len(a.Conditions) != len(b.Conditions) {
+		return false
+	}
+	for i := range a.Conditions {
+		ac, bc := a.Conditions[i], b.Conditions[i]
+		if ac.Type != bc.Type || ac.Status != bc.Status || ac.Reason != bc.Reason || ac.Message != bc.Message || ac.ObservedGeneration != bc.ObservedGeneration {
 			return false
 		}
-		return gvk == k
+	}
+	return true
+}
+
+func setReadyCondition(a *agentv1alpha1.UpboundAgent, syncErr error) {
+	c := metav1.Condition{
+		Type:               string(agentv1alpha1.TypeReady),
+		Status:             metav1.ConditionTrue,
+		Reason:             "Available",
+		Message:            "Agent deployment is available",
+		ObservedGeneration: a.Generation,
+	}
+	if syncErr != nil {
+		c.Status = metav1.ConditionFalse
+		c.Reason = "SyncFailed"
+		c.Message = syncErr.Error()
+	}
+	meta.SetStatusCondition(&a.Status.Conditions, c)
+}
+
+// setVPAUnavailableCondition reports whether VPA management was requested
+// but could not be honored because the VPA API is not installed.
+func (r *Reconciler) setVPAUnavailableCondition(a *agentv1alpha1.UpboundAgent) {
+	if !r.vpaEnabled {
+		return
+	}
+
+	c := metav1.Condition{
+		Type:               string(agentv1alpha1.TypeVPAUnavailable),
+		Status:             metav1.ConditionFalse,
+		Reason:             "VPAInstalled",
+		Message:            "VerticalPodAutoscaler API is installed",
+		ObservedGeneration: a.Generation,
+	}
+	if !r.vpaAvailable {
+		c.Status = metav1.ConditionTrue
+		c.Reason = "VPANotInstalled"
+		c.Message = "VPA management was requested but the VerticalPodAutoscaler API is not installed on this cluster"
+	}
+	meta.SetStatusCondition(&a.Status.Conditions, c)
+}
+
+func deploymentName(a *agentv1alpha1.UpboundAgent) string {
+	return a.Name
+}
+
+func desiredDeploymentSpec(a *agentv1alpha1.UpboundAgent) appsv1.DeploymentSpec {
+	env := append([]corev1.EnvVar{
+		{
+			Name: envVarToken,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: a.Spec.TokenSecretRef,
+					Key:                  keyToken,
+				},
+			},
+		},
+	}, a.Spec.Env...)
+
+	podLabels := map[string]string{
+		internalmeta.LabelKeyManagedBy: internalmeta.LabelValueManagedBy,
+		labelKeyAgentName:              a.Name,
+	}
+
+	return appsv1.DeploymentSpec{
+		Replicas: a.Spec.Replicas,
+		Selector: &metav1.LabelSelector{
+			MatchLabels: podLabels,
+		},
+		Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: podLabels,
+			},
+			Spec: corev1.PodSpec{
+				NodeSelector: a.Spec.NodeSelector,
+				Tolerations:  a.Spec.Tolerations,
+				Containers: []corev1.Container{
+					{
+						Name:      agentContainerName,
+						Image:     a.Spec.Image,
+						Args:      a.Spec.Args,
+						Env:       env,
+						Resources: a.Spec.Resources,
+					},
+				},
+			},
+		},
 	}
 }
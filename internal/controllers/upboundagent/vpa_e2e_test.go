@@ -0,0 +1,176 @@
+// Copyright 2021 Upbound Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upboundagent
+
+import (
+	"context"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	vpav1 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	agentv1alpha1 "github.com/upbound/universal-crossplane/apis/agent/v1alpha1"
+)
+
+// runVPATests gates this file's tests behind an explicit opt-in, the same
+// way kpt-config-sync gates its VPA reconciler coverage: they start a real
+// API server with the VerticalPodAutoscaler CRD installed via envtest,
+// which needs KUBEBUILDER_ASSETS and is too slow/heavy to run on every
+// `go test ./...`.
+var runVPATests = flag.Bool("vpa", false, "run VPA e2e tests against an envtest API server with the VerticalPodAutoscaler CRD installed")
+
+func TestVPALifecycleE2E(t *testing.T) {
+	if !*runVPATests {
+		t.Skip("skipping VPA e2e test; pass -vpa (with KUBEBUILDER_ASSETS set) to run it")
+	}
+
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{
+		clientgoscheme.AddToScheme,
+		agentv1alpha1.AddToScheme,
+		vpav1.AddToScheme,
+	} {
+		if err := add(scheme); err != nil {
+			t.Fatalf("add to scheme: %v", err)
+		}
+	}
+
+	env := &envtest.Environment{
+		CRDDirectoryPaths:     []string{"testdata/crds"},
+		ErrorIfCRDPathMissing: true,
+	}
+	cfg, err := env.Start()
+	if err != nil {
+		t.Fatalf("start envtest environment: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := env.Stop(); err != nil {
+			t.Logf("stop envtest environment: %v", err)
+		}
+	})
+
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{Scheme: scheme, MetricsBindAddress: "0"})
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+
+	if err := Setup(mgr, logging.NewNopLogger(), Config{
+		MaxReconcileRate: 1,
+		EnableVPA:        true,
+		VPAUpdateMode:    vpav1.UpdateModeAuto,
+	}); err != nil {
+		t.Fatalf("setup controller: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		if err := mgr.Start(ctx); err != nil {
+			t.Logf("manager exited: %v", err)
+		}
+	}()
+
+	c := mgr.GetClient()
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "vpa-e2e-"}}
+	if err := c.Create(ctx, ns); err != nil {
+		t.Fatalf("create namespace: %v", err)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "agent-token", Namespace: ns.Name},
+		Data:       map[string][]byte{keyToken: []byte("s3cr3t")},
+	}
+	if err := c.Create(ctx, secret); err != nil {
+		t.Fatalf("create token secret: %v", err)
+	}
+
+	agent := &agentv1alpha1.UpboundAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: ns.Name},
+		Spec: agentv1alpha1.UpboundAgentSpec{
+			TokenSecretRef: corev1.LocalObjectReference{Name: secret.Name},
+			Image:          "upbound/agent:v1.0.0",
+		},
+	}
+	if err := c.Create(ctx, agent); err != nil {
+		t.Fatalf("create upbound agent: %v", err)
+	}
+
+	vpaKey := types.NamespacedName{Name: agent.Name, Namespace: ns.Name}
+
+	t.Run("creates a VPA for the agent deployment", func(t *testing.T) {
+		waitFor(t, func() bool {
+			vpa := &vpav1.VerticalPodAutoscaler{}
+			return c.Get(ctx, vpaKey, vpa) == nil
+		})
+	})
+
+	t.Run("garbage collects the VPA when disabled", func(t *testing.T) {
+		latest := &agentv1alpha1.UpboundAgent{}
+		if err := c.Get(ctx, client.ObjectKeyFromObject(agent), latest); err != nil {
+			t.Fatalf("get upbound agent: %v", err)
+		}
+
+		// EnableVPA is fixed at process start in production, so exercise
+		// the "disabled" path by driving syncVPA directly against a
+		// standalone Reconciler sharing the live client, rather than
+		// re-running Setup against the already-started Manager: a second
+		// Setup call would re-register the UpboundAgent field index, which
+		// client-go rejects once the informer is serving, and would leave
+		// two controllers reconciling the same objects concurrently.
+		disabled := NewReconciler(mgr, WithVPA(false, true, "", nil, nil))
+		if err := disabled.syncVPA(ctx, latest); err != nil {
+			t.Fatalf("sync vpa with EnableVPA false: %v", err)
+		}
+
+		vpa := &vpav1.VerticalPodAutoscaler{}
+		if err := c.Get(ctx, vpaKey, vpa); !kerrors.IsNotFound(err) {
+			t.Fatalf("expected vpa to be garbage collected, got err: %v", err)
+		}
+	})
+
+	// Sanity check that the agent Deployment itself is still there and
+	// owned by the UpboundAgent throughout the VPA churn above.
+	dep := &appsv1.Deployment{}
+	if err := c.Get(ctx, vpaKey, dep); err != nil {
+		t.Fatalf("get agent deployment: %v", err)
+	}
+	if !metav1.IsControlledBy(dep, agent) {
+		t.Fatalf("agent deployment is not controlled by upbound agent %q", agent.Name)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	t.Fatal("condition not met within 30s")
+}